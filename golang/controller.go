@@ -0,0 +1,42 @@
+package main
+
+// AutomationPoint はノート発音中のある時刻における自動化パラメータのスナップショット。
+// Timeはノート開始からの相対秒数。
+type AutomationPoint struct {
+	Time       float64
+	Bend       float64 // 半音単位のピッチベンド量
+	ModWheel   float64 // 0.0-1.0（CC1）
+	Volume     float64 // 0.0-1.0（CC7、既定1.0）
+	Expression float64 // 0.0-1.0（CC11、既定1.0）
+}
+
+// sampleAutomation はAutomationPointの列を時刻tで線形補間してサンプリングする。
+// 列が空ならベンド0・モジュレーション0・音量/エクスプレッション1.0を返す。
+func sampleAutomation(points []AutomationPoint, t float64) (bend, modWheel, volume, expression float64) {
+	if len(points) == 0 {
+		return 0, 0, 1.0, 1.0
+	}
+
+	if t <= points[0].Time {
+		p := points[0]
+		return p.Bend, p.ModWheel, p.Volume, p.Expression
+	}
+
+	for i := 0; i < len(points)-1; i++ {
+		a, b := points[i], points[i+1]
+		if t >= a.Time && t <= b.Time {
+			span := b.Time - a.Time
+			if span <= 0 {
+				return b.Bend, b.ModWheel, b.Volume, b.Expression
+			}
+			frac := (t - a.Time) / span
+			return a.Bend + (b.Bend-a.Bend)*frac,
+				a.ModWheel + (b.ModWheel-a.ModWheel)*frac,
+				a.Volume + (b.Volume-a.Volume)*frac,
+				a.Expression + (b.Expression-a.Expression)*frac
+		}
+	}
+
+	last := points[len(points)-1]
+	return last.Bend, last.ModWheel, last.Volume, last.Expression
+}
@@ -0,0 +1,156 @@
+package main
+
+import "math"
+
+// ドラムボイスの種類
+type DrumVoice int
+
+const (
+	VoiceKick DrumVoice = iota
+	VoiceSnare
+	VoiceClosedHat
+	VoiceOpenHat
+	VoiceTom
+	VoiceCymbal
+	VoiceRim
+	VoiceGeneric
+)
+
+// DrumKit はGMパーカッションキー(35-81)からドラムボイスへのマッピングを保持する
+type DrumKit struct {
+	voices map[int]DrumVoice
+}
+
+// NewGMDrumKit はGeneral MIDIのドラムキー割り当てに基づくデフォルトキットを作る
+func NewGMDrumKit() *DrumKit {
+	kit := &DrumKit{voices: make(map[int]DrumVoice)}
+
+	kicks := []int{35, 36}
+	snares := []int{38, 40, 37} // 37(Side Stick)は下でVoiceRimに上書きされる
+	closedHats := []int{42, 44}
+	openHats := []int{46}
+	toms := []int{41, 43, 45, 47, 48, 50}
+	cymbals := []int{49, 51, 52, 53, 55, 57, 59}
+
+	assign := func(keys []int, v DrumVoice) {
+		for _, k := range keys {
+			kit.voices[k] = v
+		}
+	}
+	assign(kicks, VoiceKick)
+	assign(snares, VoiceSnare)
+	assign(closedHats, VoiceClosedHat)
+	assign(openHats, VoiceOpenHat)
+	assign(toms, VoiceTom)
+	assign(cymbals, VoiceCymbal)
+	kit.voices[31] = VoiceRim // Sticks
+	kit.voices[37] = VoiceRim // Side Stick
+
+	return kit
+}
+
+// lookup は未割り当てのキーをVoiceGenericに落とす
+func (k *DrumKit) lookup(note int) DrumVoice {
+	if v, ok := k.voices[note]; ok {
+		return v
+	}
+	return VoiceGeneric
+}
+
+// applyDecayEnvelope は指数的な減衰エンベロープを波形にかける
+func applyDecayEnvelope(waveform []float64, sampleRate int, decay float64) {
+	for i := range waveform {
+		t := float64(i) / float64(sampleRate)
+		waveform[i] *= math.Exp(-t / decay)
+	}
+}
+
+// pitchSweep は三角波ベースのピッチスイープ（キック/タム用）を生成する
+func pitchSweep(startFreq, endFreq float64, sampleRate int, duration float64) []float64 {
+	samples := int(float64(sampleRate) * duration)
+	waveform := make([]float64, samples)
+	phase := 0.0
+	for i := 0; i < samples; i++ {
+		t := float64(i) / float64(sampleRate)
+		progress := t / duration
+		freq := startFreq + (endFreq-startFreq)*progress
+		phase += freq / float64(sampleRate)
+		frac := math.Mod(phase, 1.0)
+		if frac < 0.5 {
+			waveform[i] = 4.0*frac - 1.0
+		} else {
+			waveform[i] = 3.0 - 4.0*frac
+		}
+	}
+	return waveform
+}
+
+// metallicVoice はシンバル用の金属的な音色を作る。非整数倍の正弦波の加算と、
+// 1サンプルごとに符号が反転する±0.3の成分で構成し、FMやリングモジュレーションは使わない
+func metallicVoice(sampleRate int, duration float64) []float64 {
+	samples := int(float64(sampleRate) * duration)
+	waveform := make([]float64, samples)
+	// 非整数倍の周波数をいくつか重ねると金属的な響きになる
+	partials := []float64{1.0, 1.47, 2.09, 2.76, 3.36}
+	for i := 0; i < samples; i++ {
+		t := float64(i) / float64(sampleRate)
+		sum := 0.0
+		for _, p := range partials {
+			sum += math.Sin(2 * math.Pi * 3000.0 * p * t)
+		}
+		noise := 0.0
+		if i%2 == 0 {
+			noise = 0.3
+		} else {
+			noise = -0.3
+		}
+		waveform[i] = sum/float64(len(partials))*0.7 + noise
+	}
+	return waveform
+}
+
+// Voice はGMパーカッションキーを合成済みドラムヒットの波形に変換する
+func (k *DrumKit) Voice(note int, sampleRate int) []float64 {
+	switch k.lookup(note) {
+	case VoiceKick:
+		waveform := pitchSweep(150.0, 50.0, sampleRate, 0.18)
+		applyDecayEnvelope(waveform, sampleRate, 0.06)
+		return waveform
+
+	case VoiceSnare:
+		waveform := generateNoise(true, sampleRate, 0.15)
+		applyDecayEnvelope(waveform, sampleRate, 0.05)
+		return waveform
+
+	case VoiceClosedHat:
+		waveform := generateNoise(true, sampleRate, 0.05)
+		applyDecayEnvelope(waveform, sampleRate, 0.015)
+		return waveform
+
+	case VoiceOpenHat:
+		waveform := generateNoise(false, sampleRate, 0.3)
+		applyDecayEnvelope(waveform, sampleRate, 0.12)
+		return waveform
+
+	case VoiceTom:
+		freq := midiNoteToFrequency(note) * 0.5
+		waveform := pitchSweep(freq*1.3, freq*0.8, sampleRate, 0.2)
+		applyDecayEnvelope(waveform, sampleRate, 0.09)
+		return waveform
+
+	case VoiceCymbal:
+		waveform := metallicVoice(sampleRate, 0.8)
+		applyDecayEnvelope(waveform, sampleRate, 0.4)
+		return waveform
+
+	case VoiceRim:
+		waveform := generateNoise(true, sampleRate, 0.03)
+		applyDecayEnvelope(waveform, sampleRate, 0.008)
+		return waveform
+
+	default:
+		waveform := generateNoise(true, sampleRate, 0.1)
+		applyDecayEnvelope(waveform, sampleRate, 0.04)
+		return waveform
+	}
+}
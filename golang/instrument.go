@@ -0,0 +1,160 @@
+package main
+
+import "math"
+
+// APUVoiceKind はNES APUのチャンネル種別
+type APUVoiceKind int
+
+const (
+	KindPulse125 APUVoiceKind = iota // デューティ12.5%の矩形波
+	KindPulse25                      // デューティ25%の矩形波
+	KindPulse50                      // デューティ50%の矩形波
+	KindPulse75                      // デューティ75%の矩形波
+	KindTriangle                     // 三角波（ベース向け）
+	KindNoise                        // LFSRノイズ（パーカッション的な音色向け）
+)
+
+// EnvelopeShape はインストゥルメントの音量エンベロープの形
+type EnvelopeShape int
+
+const (
+	EnvelopeSustained  EnvelopeShape = iota // アタック後は減衰せず持続（オルガン/パッド系）
+	EnvelopePercussive                      // アタック後に減衰するプラック系（ピアノ/ギター/ベース系）
+)
+
+// Instrument はGMプログラム番号1つ分のNES APUボイスへのマッピング
+type Instrument struct {
+	Kind         APUVoiceKind
+	Envelope     EnvelopeShape
+	VibratoRate  float64 // Hz、0ならビブラートなし
+	VibratoDepth float64 // 半音単位の深さ
+}
+
+// instrumentForProgram はGMプログラム番号(0-127)をNES APUボイスプロファイルに割り当てる。
+// GMのファミリー区分（8音ずつ）をおおまかな音色の近さでAPUチャンネルへ振り分けている。
+func instrumentForProgram(program int) Instrument {
+	switch {
+	case program >= 0 && program <= 7: // Piano
+		return Instrument{Kind: KindPulse50, Envelope: EnvelopePercussive}
+	case program >= 8 && program <= 15: // Chromatic Percussion
+		return Instrument{Kind: KindPulse25, Envelope: EnvelopePercussive}
+	case program >= 16 && program <= 23: // Organ
+		return Instrument{Kind: KindPulse50, Envelope: EnvelopeSustained}
+	case program >= 24 && program <= 31: // Guitar
+		return Instrument{Kind: KindPulse25, Envelope: EnvelopePercussive, VibratoRate: 5.0, VibratoDepth: 0.15}
+	case program >= 32 && program <= 39: // Bass
+		return Instrument{Kind: KindTriangle, Envelope: EnvelopePercussive}
+	case program >= 40 && program <= 47: // Strings
+		return Instrument{Kind: KindPulse125, Envelope: EnvelopeSustained, VibratoRate: 5.5, VibratoDepth: 0.25}
+	case program >= 48 && program <= 55: // Ensemble
+		return Instrument{Kind: KindPulse25, Envelope: EnvelopeSustained}
+	case program >= 56 && program <= 63: // Brass
+		return Instrument{Kind: KindPulse50, Envelope: EnvelopeSustained, VibratoRate: 6.0, VibratoDepth: 0.2}
+	case program >= 64 && program <= 71: // Reed
+		return Instrument{Kind: KindPulse25, Envelope: EnvelopeSustained, VibratoRate: 4.5, VibratoDepth: 0.15}
+	case program >= 72 && program <= 79: // Pipe
+		return Instrument{Kind: KindPulse75, Envelope: EnvelopeSustained, VibratoRate: 4.0, VibratoDepth: 0.2}
+	case program >= 80 && program <= 87: // Synth Lead
+		return Instrument{Kind: KindPulse125, Envelope: EnvelopePercussive}
+	case program >= 88 && program <= 95: // Synth Pad
+		return Instrument{Kind: KindTriangle, Envelope: EnvelopeSustained}
+	case program >= 96 && program <= 111: // Synth Effects, Ethnic
+		return Instrument{Kind: KindPulse75, Envelope: EnvelopePercussive}
+	default: // Percussive(112-119), Sound Effects(120-127)
+		return Instrument{Kind: KindNoise, Envelope: EnvelopePercussive}
+	}
+}
+
+// envelopeAt はインストゥルメントの音量エンベロープをt(秒)、duration(秒)について評価する
+func envelopeAt(instr Instrument, t, duration float64) float64 {
+	const attack = 0.01
+	if t < attack {
+		return t / attack
+	}
+	if instr.Envelope == EnvelopeSustained {
+		return 1.0
+	}
+
+	const decay = 0.2
+	const sustainLevel = 0.55
+	decayT := t - attack
+	if decayT >= decay {
+		return sustainLevel
+	}
+	return 1.0 - (1.0-sustainLevel)*(decayT/decay)
+}
+
+// モジュレーションホイール(CC1)がかけるビブラートのレートと深さ（半音単位）
+const modWheelVibratoRate = 5.0
+const modWheelVibratoDepth = 0.5
+
+// generateInstrumentWaveform はビブラートLFO・ピッチベンド/モジュレーションの自動化曲線・
+// エンベロープを適用しつつ、インストゥルメントのAPUボイス種別に応じた波形を1サンプルずつ生成する
+func generateInstrumentWaveform(instr Instrument, note Note, baseFrequency float64, duty DutyCycle, sampleRate int, duration float64) []float64 {
+	samples := int(float64(sampleRate) * duration)
+	waveform := make([]float64, samples)
+	dutyValue := getDutyCycleValue(duty)
+	phase := 0.0
+	lfsr := uint16(1) // KindNoise用の15ビットLFSR（generateNoiseの長周期モードと同じタップ）
+
+	for i := 0; i < samples; i++ {
+		t := float64(i) / float64(sampleRate)
+
+		bend, modWheel, volume, expression := sampleAutomation(note.Automation, t)
+
+		semitones := bend
+		if instr.VibratoRate > 0 {
+			semitones += instr.VibratoDepth * math.Sin(2*math.Pi*instr.VibratoRate*t)
+		}
+		semitones += modWheel * modWheelVibratoDepth * math.Sin(2*math.Pi*modWheelVibratoRate*t)
+
+		freq := baseFrequency * math.Pow(2.0, semitones/12.0)
+		prevPhase := phase
+		phase += freq / float64(sampleRate)
+		frac := math.Mod(phase, 1.0)
+
+		var raw float64
+		switch instr.Kind {
+		case KindTriangle:
+			if frac < 0.5 {
+				raw = 4.0*frac - 1.0
+			} else {
+				raw = 3.0 - 4.0*frac
+			}
+		case KindNoise: // 位相が1周するたびにLFSRを1ステップ進める（周波数がノイズの周期を決める）
+			for c := int(phase) - int(prevPhase); c > 0; c-- {
+				feedback := (lfsr & 1) ^ ((lfsr >> 1) & 1)
+				lfsr = (lfsr >> 1) | (feedback << 14)
+			}
+			if lfsr&1 == 1 {
+				raw = 1.0
+			} else {
+				raw = -1.0
+			}
+		default: // パルス系はデューティサイクルで矩形を描く
+			if frac < dutyValue {
+				raw = 1.0
+			} else {
+				raw = -1.0
+			}
+		}
+
+		waveform[i] = raw * envelopeAt(instr, t, duration) * volume * expression
+	}
+
+	return waveform
+}
+
+// dutyForKind はAPUボイス種別に対応するデューティサイクルを返す（パルス系以外は無視される）
+func dutyForKind(kind APUVoiceKind) DutyCycle {
+	switch kind {
+	case KindPulse125:
+		return Duty12_5
+	case KindPulse25:
+		return Duty25
+	case KindPulse75:
+		return Duty75
+	default:
+		return Duty50
+	}
+}
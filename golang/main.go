@@ -7,6 +7,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 )
 
@@ -34,17 +35,52 @@ const (
 	NoteOn MidiEventType = iota
 	NoteOff
 	ProgramChange
+	Meta
+	ControlChange
+	PitchBend
+	Aftertouch
 	Unknown
 )
 
+// よく使うコントロールチェンジ番号
+const (
+	CCModWheel       = 1
+	CCVolume         = 7
+	CCPan            = 10
+	CCExpression     = 11
+	CCDataEntryMSB   = 6
+	CCRPNLSB         = 100
+	CCRPNMSB         = 101
+	CCSustain        = 64
+	CCAllSoundOff    = 120
+	CCAllNotesOff    = 123
+)
+
+// メタイベントの種類（0xFFに続くタイプバイト）
+const (
+	MetaTrackName    = 0x03
+	MetaText         = 0x01
+	MetaMarker       = 0x06
+	MetaLyric        = 0x05
+	MetaEndOfTrack   = 0x2F
+	MetaSetTempo     = 0x51
+	MetaTimeSig      = 0x58
+	MetaKeySig       = 0x59
+)
+
 // MIDIイベント
 type MidiEvent struct {
-	Type      MidiEventType
-	Channel   int
-	Note      int
-	Velocity  int
-	Program   int
-	DeltaTime int
+	Type       MidiEventType
+	Channel    int
+	Note       int
+	Velocity   int
+	Program    int
+	DeltaTime  int
+	MetaType   byte   // Type == Meta の場合のメタイベント種別
+	Data       []byte // Type == Meta の場合のペイロード
+	Controller int    // Type == ControlChange の場合のコントローラー番号
+	Value      int    // Type == ControlChange の場合の値(0-127)
+	Bend       int    // Type == PitchBend の場合の値（-8192〜8191、中央0）
 }
 
 // MIDIトラック
@@ -62,11 +98,14 @@ type MidiFile struct {
 
 // 音符情報
 type Note struct {
-	MidiNote  int
-	Channel   int
-	StartTime float64
-	Duration  float64
-	Velocity  int
+	MidiNote   int
+	Channel    int
+	StartTime  float64
+	Duration   float64
+	Velocity   int
+	Program    int               // 発音時点でチャンネルにセットされていたGMプログラム番号
+	Pan        float64           // 発音時点のパン（-1.0=左 〜 1.0=右）。CC10未受信ならチャンネル既定値
+	Automation []AutomationPoint // 発音中のピッチベンド/モジュレーション/音量の推移（ノート開始からの秒数順）
 }
 
 // 矩形波のデューティサイクル
@@ -250,15 +289,85 @@ func parseMidiEvent(reader io.Reader, runningStatus *int, deltaTime int) (MidiEv
 		}
 		event.Program = int(program[0])
 
+	case 0xA0: // Polyphonic Aftertouch
+		event.Type = Aftertouch
+		event.Channel = status & 0x0F
+
+		var noteVal [2]byte
+		if firstByte[0] < 128 {
+			noteVal[0] = firstByte[0]
+			_, err = reader.Read(noteVal[1:])
+		} else {
+			_, err = reader.Read(noteVal[:])
+		}
+		if err != nil {
+			return event, err
+		}
+		event.Note = int(noteVal[0])
+		event.Velocity = int(noteVal[1])
+
+	case 0xB0: // Control Change
+		event.Type = ControlChange
+		event.Channel = status & 0x0F
+
+		var ctrlVal [2]byte
+		if firstByte[0] < 128 {
+			ctrlVal[0] = firstByte[0]
+			_, err = reader.Read(ctrlVal[1:])
+		} else {
+			_, err = reader.Read(ctrlVal[:])
+		}
+		if err != nil {
+			return event, err
+		}
+		event.Controller = int(ctrlVal[0])
+		event.Value = int(ctrlVal[1])
+
+	case 0xD0: // Channel Aftertouch
+		event.Type = Aftertouch
+		event.Channel = status & 0x0F
+		event.Note = -1 // チャンネル全体へのアフタータッチであることを示す
+
+		var pressure [1]byte
+		if firstByte[0] < 128 {
+			pressure[0] = firstByte[0]
+		} else {
+			_, err = reader.Read(pressure[:])
+			if err != nil {
+				return event, err
+			}
+		}
+		event.Velocity = int(pressure[0])
+
+	case 0xE0: // Pitch Bend：2データバイトで14ビットの符号付き値を構成する
+		event.Type = PitchBend
+		event.Channel = status & 0x0F
+
+		var bendBytes [2]byte
+		if firstByte[0] < 128 {
+			bendBytes[0] = firstByte[0]
+			_, err = reader.Read(bendBytes[1:])
+		} else {
+			_, err = reader.Read(bendBytes[:])
+		}
+		if err != nil {
+			return event, err
+		}
+		value14 := (int(bendBytes[1]) << 7) | int(bendBytes[0])
+		event.Bend = value14 - 8192
+
 	default:
 		event.Type = Unknown
-		// その他のイベントは適切にスキップ
-		if status == 0xFF { // Meta event
+		if status == 0xFF { // Meta event：種別とペイロードを保持する
 			var eventType [1]byte
 			reader.Read(eventType[:])
 			length, _ := readVLQ(reader)
 			data := make([]byte, length)
 			reader.Read(data)
+
+			event.Type = Meta
+			event.MetaType = eventType[0]
+			event.Data = data
 		} else if status >= 0x80 {
 			// その他のMIDIイベント
 			var dummy [1]byte
@@ -361,49 +470,242 @@ func readMidiFile(filename string) (*MidiFile, error) {
 	}, nil
 }
 
-// MIDIイベントから音符リストに変換
-func eventsToNotes(midiFile *MidiFile, tempo float64) []Note {
-	ticksToSeconds := func(tick int) float64 {
-		return float64(tick) / float64(midiFile.TicksPerQuarter) * 60.0 / tempo
+// テンポチェンジ（コンダクタートラックから収集した {tick, microsPerQuarter}）
+type TempoChange struct {
+	Tick             int
+	MicrosPerQuarter int
+}
+
+// tempoChangesInTrack は1トラック中のSet Tempoメタイベントを絶対tick順で抽出する
+func tempoChangesInTrack(track MidiTrack) []TempoChange {
+	var changes []TempoChange
+	tick := 0
+	for _, event := range track.Events {
+		tick += event.DeltaTime
+		if event.Type == Meta && event.MetaType == MetaSetTempo && len(event.Data) == 3 {
+			micros := int(event.Data[0])<<16 | int(event.Data[1])<<8 | int(event.Data[2])
+			changes = append(changes, TempoChange{Tick: tick, MicrosPerQuarter: micros})
+		}
+	}
+	return changes
+}
+
+// buildTempoMap はフォーマット1ならコンダクタートラック(先頭)、それ以外は全トラックから
+// テンポマップを構築する。明示的なSet Tempoが無い場合はCLIで指定されたテンポをtick 0に置く。
+func buildTempoMap(midiFile *MidiFile, defaultTempo float64) []TempoChange {
+	var changes []TempoChange
+	if midiFile.Format == 1 && len(midiFile.Tracks) > 0 {
+		changes = tempoChangesInTrack(midiFile.Tracks[0])
+	} else {
+		for _, track := range midiFile.Tracks {
+			changes = append(changes, tempoChangesInTrack(track)...)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Tick < changes[j].Tick })
+
+	defaultMicros := int(60000000.0 / defaultTempo)
+	if len(changes) == 0 || changes[0].Tick != 0 {
+		changes = append([]TempoChange{{Tick: 0, MicrosPerQuarter: defaultMicros}}, changes...)
+	}
+	return changes
+}
+
+// makeTicksToSeconds はテンポマップを区分的に積分し、tickを秒へ変換する関数を返す
+func makeTicksToSeconds(ticksPerQuarter int, tempoMap []TempoChange) func(tick int) float64 {
+	return func(targetTick int) float64 {
+		seconds := 0.0
+		for i, change := range tempoMap {
+			if change.Tick >= targetTick {
+				break
+			}
+			segEnd := targetTick
+			if i+1 < len(tempoMap) && tempoMap[i+1].Tick < segEnd {
+				segEnd = tempoMap[i+1].Tick
+			}
+			ticks := segEnd - change.Tick
+			if ticks <= 0 {
+				continue
+			}
+			microsPerTick := float64(change.MicrosPerQuarter) / float64(ticksPerQuarter)
+			seconds += float64(ticks) * microsPerTick / 1e6
+		}
+		return seconds
 	}
+}
 
-	noteOnEvents := make(map[string]struct {
-		velocity  int
-		startTime float64
-	})
+// pendingNote は発音中のノート1つ分の状態。サステイン中はNoteOffが来てもすぐには確定させず、
+// released フラグだけ立てて、ペダルが離されるかハードカットが来るまで保持する。
+type pendingNote struct {
+	channel    int
+	midiNote   int
+	velocity   int
+	startTime  float64
+	program    int
+	pan        float64
+	automation []AutomationPoint
+	released   bool
+}
 
+// channelControlState はチャンネルごとのコントローラー/ピッチベンドの現在値
+type channelControlState struct {
+	sustain    bool
+	bend       float64 // 半音単位
+	modWheel   float64 // 0.0-1.0
+	volume     float64 // 0.0-1.0（CC7、既定1.0）
+	expression float64 // 0.0-1.0（CC11、既定1.0）
+	bendRange  float64 // 半音単位（既定2）
+	pan        float64 // -1.0(左)〜1.0(右)。CC10未受信ならチャンネル既定の簡易パン
+	rpnMSB     int
+	rpnLSB     int
+}
+
+// newChannelControlState はチャンネル既定のパン値をpanForChannelから引き継いだ初期状態を作る
+func newChannelControlState(channel int) channelControlState {
+	return channelControlState{
+		volume: 1.0, expression: 1.0, bendRange: 2.0,
+		pan: panForChannel(channel), rpnMSB: 127, rpnLSB: 127,
+	}
+}
+
+// MIDIイベントから音符リストに変換
+func eventsToNotes(midiFile *MidiFile, tempo float64) []Note {
+	tempoMap := buildTempoMap(midiFile, tempo)
+	ticksToSeconds := makeTicksToSeconds(midiFile.TicksPerQuarter, tempoMap)
+
+	noteOnEvents := make(map[string]*pendingNote)
 	var notes []Note
 
+	finalize := func(key string, pn *pendingNote, endTick int) {
+		endTime := ticksToSeconds(endTick)
+		duration := endTime - pn.startTime
+		if duration > 0 {
+			notes = append(notes, Note{
+				MidiNote:   pn.midiNote,
+				Channel:    pn.channel,
+				StartTime:  pn.startTime,
+				Duration:   duration,
+				Velocity:   pn.velocity,
+				Program:    pn.program,
+				Pan:        pn.pan,
+				Automation: pn.automation,
+			})
+		}
+		delete(noteOnEvents, key)
+	}
+
 	for _, track := range midiFile.Tracks {
 		currentTick := 0
+		currentProgram := make(map[int]int)
+		channels := make(map[int]*channelControlState)
+		channelState := func(ch int) *channelControlState {
+			if s, ok := channels[ch]; ok {
+				return s
+			}
+			s := newChannelControlState(ch)
+			channels[ch] = &s
+			return channels[ch]
+		}
+
+		logAutomation := func(ch int, tick int) {
+			st := channelState(ch)
+			for _, pn := range noteOnEvents {
+				if pn.channel != ch {
+					continue
+				}
+				pn.automation = append(pn.automation, AutomationPoint{
+					Time:       ticksToSeconds(tick) - pn.startTime,
+					Bend:       st.bend,
+					ModWheel:   st.modWheel,
+					Volume:     st.volume,
+					Expression: st.expression,
+				})
+			}
+		}
+
 		for _, event := range track.Events {
 			currentTick += event.DeltaTime
-
 			key := fmt.Sprintf("%d-%d", event.Channel, event.Note)
 
 			switch event.Type {
+			case ProgramChange:
+				currentProgram[event.Channel] = event.Program
+
 			case NoteOn:
-				startTime := ticksToSeconds(currentTick)
-				noteOnEvents[key] = struct {
-					velocity  int
-					startTime float64
-				}{event.Velocity, startTime}
+				st := channelState(event.Channel)
+				if old, exists := noteOnEvents[key]; exists && old.released {
+					// ペダルで保留中だった同じキーのノートを、再トリガー前に確定させる
+					finalize(key, old, currentTick)
+				}
+				noteOnEvents[key] = &pendingNote{
+					channel:   event.Channel,
+					midiNote:  event.Note,
+					velocity:  event.Velocity,
+					startTime: ticksToSeconds(currentTick),
+					program:   currentProgram[event.Channel],
+					pan:       st.pan,
+					automation: []AutomationPoint{
+						{Time: 0, Bend: st.bend, ModWheel: st.modWheel, Volume: st.volume, Expression: st.expression},
+					},
+				}
 
 			case NoteOff:
-				if noteOn, exists := noteOnEvents[key]; exists {
-					endTime := ticksToSeconds(currentTick)
-					duration := endTime - noteOn.startTime
-
-					if duration > 0 {
-						notes = append(notes, Note{
-							MidiNote:  event.Note,
-							Channel:   event.Channel,
-							StartTime: noteOn.startTime,
-							Duration:  duration,
-							Velocity:  noteOn.velocity,
-						})
+				if pn, exists := noteOnEvents[key]; exists {
+					st := channelState(event.Channel)
+					if st.sustain {
+						pn.released = true
+					} else {
+						finalize(key, pn, currentTick)
+					}
+				}
+
+			case PitchBend:
+				st := channelState(event.Channel)
+				st.bend = float64(event.Bend) / 8192.0 * st.bendRange
+				logAutomation(event.Channel, currentTick)
+
+			case ControlChange:
+				st := channelState(event.Channel)
+				switch event.Controller {
+				case CCModWheel:
+					st.modWheel = float64(event.Value) / 127.0
+					logAutomation(event.Channel, currentTick)
+				case CCVolume:
+					st.volume = float64(event.Value) / 127.0
+					logAutomation(event.Channel, currentTick)
+				case CCExpression:
+					st.expression = float64(event.Value) / 127.0
+					logAutomation(event.Channel, currentTick)
+				case CCPan:
+					if event.Value < 64 {
+						st.pan = (float64(event.Value) - 64.0) / 64.0
+					} else {
+						st.pan = (float64(event.Value) - 64.0) / 63.0
+					}
+				case CCRPNMSB:
+					st.rpnMSB = event.Value
+				case CCRPNLSB:
+					st.rpnLSB = event.Value
+				case CCDataEntryMSB:
+					if st.rpnMSB == 0 && st.rpnLSB == 0 { // RPN 0,0 = Pitch Bend Range
+						st.bendRange = float64(event.Value)
+					}
+				case CCSustain:
+					wasOn := st.sustain
+					st.sustain = event.Value >= 64
+					if wasOn && !st.sustain { // ペダルが離された：保留中のノートを確定させる
+						for k, pn := range noteOnEvents {
+							if pn.channel == event.Channel && pn.released {
+								finalize(k, pn, currentTick)
+							}
+						}
+					}
+				case CCAllSoundOff, CCAllNotesOff:
+					for k, pn := range noteOnEvents {
+						if pn.channel == event.Channel {
+							finalize(k, pn, currentTick)
+						}
 					}
-					delete(noteOnEvents, key)
 				}
 			}
 		}
@@ -477,38 +779,81 @@ func generateNoise(isShort bool, sampleRate int, duration float64) []float64 {
 	return waveform
 }
 
-// 音符を波形に変換
-func noteToWaveform(note Note, sampleRate int, totalDuration float64) []float64 {
+// 音符を波形に変換（sf2Fontが設定されていればSF2サンプルベースの合成を使う）
+// noteToWaveform は音符1つ分の波形だけを返す（曲全体の長さには引き延ばさない）。
+// 呼び出し側が note.StartTime に応じたオフセットで出力バッファに足し込む。
+func noteToWaveform(note Note, sampleRate int) []float64 {
 	frequency := midiNoteToFrequency(note.MidiNote)
 	volume := float64(note.Velocity) / 127.0 * 0.7
-	startSample := int(note.StartTime * float64(sampleRate))
-	noteSamples := int(note.Duration * float64(sampleRate))
-	totalSamples := int(totalDuration * float64(sampleRate))
+
+	if sf2Font != nil {
+		if sfWave := sf2Font.Voice(note, sampleRate); sfWave != nil {
+			return sfWave // Velocityによる音量はVoice内で適用済み
+		}
+	}
 
 	var waveform []float64
+	if note.Channel == 9 { // GMチャンネル10：パーカッション
+		waveform = defaultDrumKit.Voice(note.MidiNote, sampleRate)
+	} else {
+		instr := instrumentForProgram(note.Program)
+		waveform = generateInstrumentWaveform(instr, note, frequency, dutyForKind(instr.Kind), sampleRate, note.Duration)
+	}
 
-	switch note.Channel % 4 {
-	case 0:
-		waveform = generateSquareWave(frequency, Duty50, sampleRate, note.Duration)
-	case 1:
-		waveform = generateSquareWave(frequency, Duty25, sampleRate, note.Duration)
-	case 2:
-		waveform = generateTriangleWave(frequency, sampleRate, note.Duration)
-	default:
-		waveform = generateNoise(false, sampleRate, note.Duration)
+	for i := range waveform {
+		waveform[i] *= volume
 	}
+	return waveform
+}
 
-	result := make([]float64, totalSamples)
-	endSample := startSample + noteSamples
-	if endSample > totalSamples {
-		endSample = totalSamples
+// classifyVoiceKind は1つのNoteがAPUミキサーのどのバケツ（パルス/三角/ノイズ）に属するかを判定する
+func classifyVoiceKind(note Note) APUVoiceKind {
+	if note.Channel == 9 { // パーカッション：キック/タムは三角波系、それ以外はノイズ系として扱う
+		switch defaultDrumKit.lookup(note.MidiNote) {
+		case VoiceKick, VoiceTom:
+			return KindTriangle
+		default:
+			return KindNoise
+		}
 	}
+	return instrumentForProgram(note.Program).Kind
+}
+
+// toDAC は[-1,1]の波形サンプルをNES APUの4ビットDACレンジ(0-15)に写像する
+func toDAC(sample float64) float64 {
+	return (sample + 1.0) / 2.0 * 15.0
+}
 
-	for i := startSample; i < endSample && i-startSample < len(waveform); i++ {
-		result[i] = waveform[i-startSample] * volume
+// pulseMixerOut はNESの非線形パルスミキサー式: 95.88 / (8128/(p1+p2) + 100)
+func pulseMixerOut(pulseDAC float64) float64 {
+	if pulseDAC <= 0 {
+		return 0
 	}
+	return 95.88 / (8128.0/pulseDAC + 100.0)
+}
 
-	return result
+// tndMixerOut はNESの非線形TND(三角/ノイズ/DMC)ミキサー式:
+// 159.79 / (1/(triangle/8227 + noise/12241 + dmc/22638) + 100)
+func tndMixerOut(triDAC, noiseDAC, dmcDAC float64) float64 {
+	denom := triDAC/8227.0 + noiseDAC/12241.0 + dmcDAC/22638.0
+	if denom <= 0 {
+		return 0
+	}
+	return 159.79 / (1.0/denom + 100.0)
+}
+
+// panForChannel はMIDIチャンネルを-1.0(左)〜1.0(右)の簡易パンマップに割り当てる
+func panForChannel(channel int) float64 {
+	if channel == 9 { // パーカッションはセンター
+		return 0.0
+	}
+	positions := []float64{-0.6, 0.6, -0.3, 0.3, -0.9, 0.9, 0.0}
+	return positions[channel%len(positions)]
+}
+
+// panGains は線形パン則でL/R各チャンネルのゲインを返す
+func panGains(pan float64) (left, right float64) {
+	return (1.0 - pan) / 2.0, (1.0 + pan) / 2.0
 }
 
 // 複数の波形をミックス
@@ -590,9 +935,15 @@ func writeWaveFile(filename string, samples []float64, sampleRate int) error {
 	return nil
 }
 
+// sf2Fontが非nilの場合、noteToWaveformはチップチューン合成の代わりにSF2サンプル合成を使う
+var sf2Font *SF2Font
+
+// GMチャンネル10（0-indexedで9）用のデフォルトドラムキット
+var defaultDrumKit = NewGMDrumKit()
+
 // MIDIからWAV変換のメイン関数
 func convertMidiToWav(midiFilename, wavFilename string, tempo float64) error {
-	midiFile, err := readMidiFile(midiFilename)
+	midiFile, err := readScoreFile(midiFilename)
 	if err != nil {
 		return fmt.Errorf("MIDI file read error: %v", err)
 	}
@@ -600,6 +951,11 @@ func convertMidiToWav(midiFilename, wavFilename string, tempo float64) error {
 	fmt.Printf("MIDIファイルを読み込みました: Format %d, %d tracks, %d ticks/quarter\n",
 		midiFile.Format, midiFile.TrackCount, midiFile.TicksPerQuarter)
 
+	tempoMap := buildTempoMap(midiFile, tempo)
+	if len(tempoMap) > 1 {
+		fmt.Printf("テンポチェンジを%d個検出しました\n", len(tempoMap)-1)
+	}
+
 	notes := eventsToNotes(midiFile, tempo)
 	fmt.Printf("%d個の音符を検出しました\n", len(notes))
 
@@ -618,21 +974,101 @@ func convertMidiToWav(midiFilename, wavFilename string, tempo float64) error {
 	totalDuration += 1.0 // 余裕を持たせる
 
 	sampleRate := 44100
+	totalSamples := int(totalDuration * float64(sampleRate))
 	fmt.Printf("総演奏時間: %.2f秒\n", totalDuration)
 	fmt.Println("波形を生成中...")
 
-	var waveforms [][]float64
+	// チャンネルごとにL/Rへ振り分けつつ、音符の数に関わらず固定本数のバッファへ直接加算する
+	// （totalSamples分の一時バッファを音符の数だけ確保していた従来の実装を避ける）
+	leftBuf := make([]float64, totalSamples)
+	rightBuf := make([]float64, totalSamples)
+	var leftPulse, rightPulse, leftTri, rightTri, leftNoise, rightNoise []float64
+	if sf2Font == nil {
+		leftPulse = make([]float64, totalSamples)
+		rightPulse = make([]float64, totalSamples)
+		leftTri = make([]float64, totalSamples)
+		rightTri = make([]float64, totalSamples)
+		leftNoise = make([]float64, totalSamples)
+		rightNoise = make([]float64, totalSamples)
+	}
+
 	for _, note := range notes {
-		waveform := noteToWaveform(note, sampleRate, totalDuration)
-		waveforms = append(waveforms, waveform)
+		waveform := noteToWaveform(note, sampleRate)
+		startSample := int(note.StartTime * float64(sampleRate))
+		leftGain, rightGain := panGains(note.Pan)
+
+		if sf2Font != nil {
+			for i, v := range waveform {
+				idx := startSample + i
+				if idx >= totalSamples {
+					break
+				}
+				leftBuf[idx] += v * leftGain
+				rightBuf[idx] += v * rightGain
+			}
+			continue
+		}
+
+		kind := classifyVoiceKind(note)
+		for i, v := range waveform {
+			idx := startSample + i
+			if idx >= totalSamples {
+				break
+			}
+			dac := toDAC(v)
+			switch kind {
+			case KindTriangle:
+				leftTri[idx] += dac * leftGain
+				rightTri[idx] += dac * rightGain
+			case KindNoise:
+				leftNoise[idx] += dac * leftGain
+				rightNoise[idx] += dac * rightGain
+			default:
+				leftPulse[idx] += dac * leftGain
+				rightPulse[idx] += dac * rightGain
+			}
+		}
+	}
+
+	if sf2Font == nil {
+		for i := 0; i < totalSamples; i++ {
+			leftBuf[i] = pulseMixerOut(leftPulse[i]) + tndMixerOut(leftTri[i], leftNoise[i], 0)
+			rightBuf[i] = pulseMixerOut(rightPulse[i]) + tndMixerOut(rightTri[i], rightNoise[i], 0)
+		}
+	}
+
+	file, err := os.Create(wavFilename)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
 
-	mixed := mixWaveforms(waveforms)
-	err = writeWaveFile(wavFilename, mixed, sampleRate)
+	writer, err := NewWavWriter(file, WavConfig{Channels: 2, SampleRate: sampleRate, Format: FormatInt16})
 	if err != nil {
 		return err
 	}
 
+	const bufferSize = 4096
+	block := make([]float64, 0, bufferSize*2)
+	for i := 0; i < totalSamples; i++ {
+		block = append(block, leftBuf[i], rightBuf[i])
+		if len(block) >= bufferSize*2 {
+			if err := writer.Write(block); err != nil {
+				return err
+			}
+			block = block[:0]
+		}
+	}
+	if len(block) > 0 {
+		if err := writer.Write(block); err != nil {
+			return err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
 	fmt.Printf("WAVファイル '%s' を生成しました！\n", wavFilename)
 	return nil
 }
@@ -675,20 +1111,45 @@ func generateDemoNESMusic() error {
 
 // メイン実行関数
 func main() {
-	if len(os.Args) >= 2 {
-		midiFile := os.Args[1]
+	args := os.Args[1:]
+
+	// -sf2 <file> オプションを抽出する（位置引数の解析には影響させない）
+	var sf2Path string
+	filtered := args[:0:0]
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-sf2" && i+1 < len(args) {
+			sf2Path = args[i+1]
+			i++
+			continue
+		}
+		filtered = append(filtered, args[i])
+	}
+	args = filtered
+
+	if sf2Path != "" {
+		font, err := loadSF2(sf2Path)
+		if err != nil {
+			fmt.Printf("SF2ファイルの読み込みに失敗しました: %v\n", err)
+			os.Exit(1)
+		}
+		sf2Font = font
+		fmt.Printf("SF2サウンドフォント '%s' を読み込みました: %d個のプリセット\n", sf2Path, len(font.Presets))
+	}
+
+	if len(args) >= 1 {
+		midiFile := args[0]
 
 		var outputFile string
-		if len(os.Args) >= 3 {
-			outputFile = os.Args[2]
+		if len(args) >= 2 {
+			outputFile = args[1]
 		} else {
 			outputFile = filepath.Base(midiFile)
 			outputFile = outputFile[:len(outputFile)-len(filepath.Ext(outputFile))] + ".wav"
 		}
 
 		tempo := 120.0
-		if len(os.Args) >= 4 {
-			if t, err := strconv.ParseFloat(os.Args[3], 64); err == nil {
+		if len(args) >= 3 {
+			if t, err := strconv.ParseFloat(args[2], 64); err == nil {
 				tempo = t
 			}
 		}
@@ -703,7 +1164,7 @@ func main() {
 			os.Exit(1)
 		}
 	} else {
-		fmt.Println("使用方法: program <MIDIファイル> [出力WAVファイル] [テンポ]")
+		fmt.Println("使用方法: program [-sf2 file.sf2] <MIDIファイル> [出力WAVファイル] [テンポ]")
 		fmt.Println("デモファイルを生成します...")
 		if err := generateDemoNESMusic(); err != nil {
 			fmt.Printf("デモ生成エラー: %v\n", err)
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// MUSイベントの種類（イベントバイトのビット4-6、最上位ビットはディレイ続行フラグ、チャンネルは下位4ビット）
+const (
+	musReleaseNote = 0
+	musPlayNote    = 1
+	musPitchBend   = 2
+	musSystemEvent = 3
+	musController  = 4
+	musScoreEnd    = 6
+)
+
+// MUSのシステムイベント（eventType==musSystemEvent）のコントローラ番号
+const (
+	musSysAllSoundsOff        = 10
+	musSysAllNotesOff         = 11
+	musSysMonoMode            = 12
+	musSysPolyMode            = 13
+	musSysResetAllControllers = 14
+)
+
+// musControllerToCC はMUSのコントローラ番号(1-9)をMIDIのCC番号へ対応付ける
+// （0=プログラムチェンジは特別扱いのためこの表には含めない）
+var musControllerToCC = map[int]int{
+	1: 0,            // バンクセレクト
+	2: CCModWheel,   // モジュレーション
+	3: CCVolume,     // ボリューム
+	4: CCPan,        // パン
+	5: CCExpression, // エクスプレッション
+	6: 91,           // リバーブ深度
+	7: 93,           // コーラス深度
+	8: CCSustain,    // サステインペダル
+	9: 67,           // ソフトペダル
+}
+
+// readMusVLQ はid SoftwareのMUS形式が使う可変長のディレイ値を読み込む
+// （連結規則はMIDIのVLQと同じ value = value*128 + (b&0x7F)。バイト列とオフセットから読み、消費バイト数も返す）
+func readMusVLQ(data []byte, pos int) (int, int) {
+	value := 0
+	consumed := 0
+	for pos+consumed < len(data) {
+		b := data[pos+consumed]
+		consumed++
+		value = value*128 + int(b&0x7F)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	return value, consumed
+}
+
+// readMusFile はid SoftwareのMUS(DMX)ファイルを読み込み、既存のMidiFile/MidiTrack構造に変換する。
+// MUSのチャンネル15（パーカッション）はMIDIのチャンネル9へ入れ替える（MUS⇔MIDIの慣例通り）。
+func readMusFile(filename string) (*MidiFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 20 || string(data[0:4]) != "MUS\x1a" {
+		return nil, fmt.Errorf("invalid MUS file: missing MUS\\x1A header")
+	}
+
+	scoreLength := binary.LittleEndian.Uint16(data[4:6])
+	scoreStart := binary.LittleEndian.Uint16(data[6:8])
+
+	pos := int(scoreStart)
+	end := pos + int(scoreLength)
+	if end > len(data) {
+		end = len(data)
+	}
+
+	lastVolume := make([]int, 16)
+	for i := range lastVolume {
+		lastVolume[i] = 100 // 明示的なボリュームが来るまでのデフォルトノートオンベロシティ
+	}
+
+	var events []MidiEvent
+	pendingDelay := 0
+
+	for pos < end {
+		eventByte := data[pos]
+		pos++
+
+		last := eventByte&0x80 != 0
+		eventType := (eventByte >> 4) & 0x07
+		channel := int(eventByte & 0x0F)
+		midiChannel := channel
+		if channel == 15 {
+			midiChannel = 9 // MUS⇔MIDIの慣例的なパーカッションチャンネルの入れ替え
+		} else if channel == 9 {
+			midiChannel = 15 // 逆方向：MUSの通常チャンネル9はMIDIの9(パーカッション)と衝突するため退避
+		}
+
+		ev := MidiEvent{DeltaTime: pendingDelay, Channel: midiChannel}
+		pendingDelay = 0
+		emit := false
+
+		switch eventType {
+		case musReleaseNote:
+			if pos >= end {
+				break
+			}
+			note := data[pos]
+			pos++
+			ev.Type = NoteOff
+			ev.Note = int(note & 0x7F)
+			emit = true
+
+		case musPlayNote:
+			if pos >= end {
+				break
+			}
+			noteByte := data[pos]
+			pos++
+			velocity := lastVolume[channel]
+			if noteByte&0x80 != 0 {
+				if pos >= end {
+					break
+				}
+				volByte := data[pos]
+				pos++
+				velocity = int(volByte & 0x7F)
+				lastVolume[channel] = velocity
+			}
+			ev.Type = NoteOn
+			ev.Note = int(noteByte & 0x7F)
+			ev.Velocity = velocity
+			emit = true
+
+		case musPitchBend:
+			if pos >= end {
+				break
+			}
+			bendByte := data[pos]
+			pos++
+			ev.Type = PitchBend
+			// MUSのベンドは0-128(中央64)の1バイト。SMF側の14ビット符号付き表現に合わせてスケールする
+			ev.Bend = (int(bendByte) - 64) * 128
+			emit = true
+
+		case musSystemEvent:
+			if pos >= end {
+				break
+			}
+			ctrlNum := data[pos]
+			pos++
+			switch ctrlNum {
+			case musSysAllSoundsOff:
+				ev.Type = ControlChange
+				ev.Controller = CCAllSoundOff
+				emit = true
+			case musSysAllNotesOff:
+				ev.Type = ControlChange
+				ev.Controller = CCAllNotesOff
+				emit = true
+			default:
+				// モノ/ポリモード切り替え、オールコントローラリセットは現状非対応
+			}
+
+		case musController:
+			if pos+1 >= end {
+				pos = end
+				break
+			}
+			ctrlNum := int(data[pos])
+			val := data[pos+1]
+			pos += 2
+			if ctrlNum == 0 { // MUSのコントローラ0 = プログラムチェンジ(パッチ)
+				ev.Type = ProgramChange
+				ev.Program = int(val)
+				emit = true
+			} else if cc, ok := musControllerToCC[ctrlNum]; ok {
+				ev.Type = ControlChange
+				ev.Controller = cc
+				ev.Value = int(val)
+				emit = true
+			}
+
+		case musScoreEnd:
+			pos = end
+
+		default:
+			pos = end
+		}
+
+		if emit {
+			events = append(events, ev)
+		}
+
+		if last && pos < end {
+			delay, consumed := readMusVLQ(data, pos)
+			pos += consumed
+			pendingDelay = delay
+		}
+	}
+
+	return &MidiFile{
+		Format:          0,
+		TrackCount:      1,
+		TicksPerQuarter: 140, // DMXエンジンが再生に使う固定ティックレート（実テンポとは無関係の近似値）
+		Tracks:          []MidiTrack{{Events: events}},
+	}, nil
+}
+
+// isMusFile はファイル先頭のマジックナンバーからMUS(DMX)形式かどうかを判定する
+func isMusFile(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var magic [4]byte
+	if _, err := f.Read(magic[:]); err != nil {
+		return false
+	}
+	return string(magic[:]) == "MUS\x1a"
+}
+
+// readScoreFile はファイルのマジックナンバーを見てMUS/SMFどちらのパーサーに渡すかを決める
+func readScoreFile(filename string) (*MidiFile, error) {
+	if isMusFile(filename) {
+		return readMusFile(filename)
+	}
+	return readMidiFile(filename)
+}
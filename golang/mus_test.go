@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadMusVLQ(t *testing.T) {
+	cases := []struct {
+		data     []byte
+		wantVal  int
+		wantUsed int
+	}{
+		{[]byte{0x0A}, 10, 1},
+		{[]byte{0x81, 0x00}, 128, 2},
+	}
+	for _, c := range cases {
+		val, used := readMusVLQ(c.data, 0)
+		if val != c.wantVal || used != c.wantUsed {
+			t.Errorf("readMusVLQ(%v) = (%d, %d), want (%d, %d)", c.data, val, used, c.wantVal, c.wantUsed)
+		}
+	}
+}
+
+// encodeMusVLQ is the test-side inverse of readMusVLQ's decoding rule, written
+// independently so the test doesn't just mirror the implementation under test.
+func encodeMusVLQ(value int) []byte {
+	if value == 0 {
+		return []byte{0x00}
+	}
+	var digits []byte
+	for v := value; v > 0; v /= 128 {
+		digits = append([]byte{byte(v % 128)}, digits...)
+	}
+	for i := 0; i < len(digits)-1; i++ {
+		digits[i] |= 0x80
+	}
+	return digits
+}
+
+func writeTempMus(t *testing.T, events []byte) string {
+	t.Helper()
+	header := make([]byte, 20)
+	copy(header[0:4], "MUS\x1a")
+	binary.LittleEndian.PutUint16(header[4:6], uint16(len(events)))
+	binary.LittleEndian.PutUint16(header[6:8], 20) // scoreStart
+
+	path := filepath.Join(t.TempDir(), "test.mus")
+	if err := os.WriteFile(path, append(header, events...), 0644); err != nil {
+		t.Fatalf("failed to write temp MUS file: %v", err)
+	}
+	return path
+}
+
+func TestIsMusFile(t *testing.T) {
+	musPath := writeTempMus(t, []byte{byte(musScoreEnd << 4)})
+	if !isMusFile(musPath) {
+		t.Errorf("expected %s to be detected as a MUS file", musPath)
+	}
+
+	other := filepath.Join(t.TempDir(), "not.mid")
+	if err := os.WriteFile(other, []byte("MThd\x00\x00\x00\x06"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if isMusFile(other) {
+		t.Errorf("expected %s not to be detected as a MUS file", other)
+	}
+}
+
+func TestReadMusFileParsesEventsAndRemapsPercussionChannel(t *testing.T) {
+	var events []byte
+
+	// NoteOn: channel 0, note 60, explicit velocity 100, followed by a delay of 10
+	events = append(events, 0x80|byte(musPlayNote<<4)|0)
+	events = append(events, 0x80|60, 100)
+	events = append(events, encodeMusVLQ(10)...)
+
+	// Pitch bend: channel 0, bend byte 96 (bend up), followed by a delay of 5
+	events = append(events, 0x80|byte(musPitchBend<<4)|0)
+	events = append(events, 96)
+	events = append(events, encodeMusVLQ(5)...)
+
+	// Controller: channel 0, MUS controller 3 (volume) -> CC7, value 80
+	events = append(events, 0x80|byte(musController<<4)|0)
+	events = append(events, 3, 80)
+	events = append(events, encodeMusVLQ(0)...)
+
+	// System event: channel 0, all notes off
+	events = append(events, 0x80|byte(musSystemEvent<<4)|0)
+	events = append(events, byte(musSysAllNotesOff))
+	events = append(events, encodeMusVLQ(0)...)
+
+	// NoteOn: MUS channel 9 (must remap to MIDI channel 15), default velocity
+	events = append(events, 0x80|byte(musPlayNote<<4)|9)
+	events = append(events, 40)
+	events = append(events, encodeMusVLQ(1)...)
+
+	// NoteOn: MUS channel 15 (must remap to MIDI channel 9, percussion), explicit velocity 120
+	events = append(events, 0x80|byte(musPlayNote<<4)|15)
+	events = append(events, 0x80|36, 120)
+	events = append(events, encodeMusVLQ(0)...)
+
+	// Score end
+	events = append(events, byte(musScoreEnd<<4))
+
+	midiFile, err := readMusFile(writeTempMus(t, events))
+	if err != nil {
+		t.Fatalf("readMusFile failed: %v", err)
+	}
+
+	if midiFile.Format != 0 || midiFile.TrackCount != 1 || midiFile.TicksPerQuarter != 140 {
+		t.Errorf("unexpected MidiFile header: %+v", midiFile)
+	}
+
+	got := midiFile.Tracks[0].Events
+	want := []MidiEvent{
+		{Type: NoteOn, Channel: 0, Note: 60, Velocity: 100, DeltaTime: 0},
+		{Type: PitchBend, Channel: 0, Bend: (96 - 64) * 128, DeltaTime: 10},
+		{Type: ControlChange, Channel: 0, Controller: CCVolume, Value: 80, DeltaTime: 5},
+		{Type: ControlChange, Channel: 0, Controller: CCAllNotesOff, DeltaTime: 0},
+		{Type: NoteOn, Channel: 15, Note: 40, Velocity: 100, DeltaTime: 0},
+		{Type: NoteOn, Channel: 9, Note: 36, Velocity: 120, DeltaTime: 1},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type || got[i].Channel != want[i].Channel ||
+			got[i].Note != want[i].Note || got[i].Velocity != want[i].Velocity ||
+			got[i].Controller != want[i].Controller || got[i].Value != want[i].Value ||
+			got[i].Bend != want[i].Bend || got[i].DeltaTime != want[i].DeltaTime {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
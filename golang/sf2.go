@@ -0,0 +1,527 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// SF2のジェネレータID（必要なものだけ）
+const (
+	genStartloopAddrsOffset = 2
+	genEndloopAddrsOffset   = 3
+	genInstrument           = 41
+	genKeyRange             = 43
+	genVelRange             = 44
+	genAttackVolEnv         = 34
+	genHoldVolEnv           = 35
+	genDecayVolEnv          = 36
+	genSustainVolEnv        = 37
+	genReleaseVolEnv        = 38
+	genSampleModes          = 54
+	genOverridingRootKey    = 58
+	genSampleID             = 53
+)
+
+// SF2ジェネレータ値
+type sf2Gen struct {
+	oper uint16
+	amt  int16
+}
+
+// SF2サンプル（shdr由来）
+type SF2Sample struct {
+	Name            string
+	Start, End      uint32
+	StartLoop       uint32
+	EndLoop         uint32
+	SampleRate      uint32
+	OriginalPitch   uint8
+	PitchCorrection int8
+	Data            []int16
+}
+
+// SF2ゾーン：ノート/ベロシティ範囲とエンベロープ、参照サンプル
+type SF2Zone struct {
+	KeyLo, KeyHi int
+	VelLo, VelHi int
+	SampleIndex  int
+	RootKey      int
+	Loop         bool
+	Attack       float64
+	Hold         float64
+	Decay        float64
+	Sustain      float64 // 0.0-1.0（アッテネーションから変換済み）
+	Release      float64
+}
+
+// SF2プリセット：MIDIバンク/プログラム番号に対応するゾーン群
+type SF2Preset struct {
+	Name    string
+	Bank    int
+	Program int
+	Zones   []SF2Zone
+}
+
+// SF2フォント全体
+type SF2Font struct {
+	Presets []SF2Preset
+	Samples []SF2Sample
+}
+
+// timecentsを秒に変換（SF2のエンベロープ指定はtimecents単位）
+func timecentsToSeconds(tc int16) float64 {
+	if tc <= -32768 {
+		return 0
+	}
+	return math.Pow(2.0, float64(tc)/1200.0)
+}
+
+// centibelsのアッテネーションを0.0-1.0のサステインレベルに変換
+func centibelsToSustainLevel(cb int16) float64 {
+	level := 1.0 - float64(cb)/1000.0
+	if level < 0 {
+		level = 0
+	}
+	if level > 1 {
+		level = 1
+	}
+	return level
+}
+
+// RIFFチャンクヘッダーの読み込み
+func readChunkHeader(r io.Reader) (string, uint32, error) {
+	var id [4]byte
+	if _, err := io.ReadFull(r, id[:]); err != nil {
+		return "", 0, err
+	}
+	var size uint32
+	if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+		return "", 0, err
+	}
+	return string(id[:]), size, nil
+}
+
+func readFixedString(b []byte) string {
+	n := 0
+	for n < len(b) && b[n] != 0 {
+		n++
+	}
+	return string(b[:n])
+}
+
+// loadSF2 はSF2(RIFF)ファイルをパースしてプリセット/サンプルテーブルを構築する
+func loadSF2(filename string) (*SF2Font, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "sfbk" {
+		return nil, fmt.Errorf("invalid SF2 file: missing RIFF/sfbk header")
+	}
+
+	var smplData []byte
+	var phdr, pbag, pgen, inst, ibag, igen, shdr []byte
+
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := data[pos+8:]
+		if int(size) > len(body) {
+			break
+		}
+		body = body[:size]
+
+		switch id {
+		case "LIST":
+			if len(body) >= 4 {
+				listType := string(body[0:4])
+				inner := body[4:]
+				switch listType {
+				case "sdta":
+					smplData = extractSubchunk(inner, "smpl")
+				case "pdta":
+					phdr = extractSubchunk(inner, "phdr")
+					pbag = extractSubchunk(inner, "pbag")
+					pgen = extractSubchunk(inner, "pgen")
+					inst = extractSubchunk(inner, "inst")
+					ibag = extractSubchunk(inner, "ibag")
+					igen = extractSubchunk(inner, "igen")
+					shdr = extractSubchunk(inner, "shdr")
+				}
+			}
+		}
+
+		pos += 8 + int(size)
+		if size%2 == 1 {
+			pos++ // パディングバイト
+		}
+	}
+
+	if phdr == nil || shdr == nil {
+		return nil, fmt.Errorf("invalid SF2 file: missing pdta/sdta chunks")
+	}
+
+	samples := parseShdr(shdr, smplData)
+	instruments := parseInstruments(inst, ibag, igen)
+	font := &SF2Font{Samples: samples}
+	font.Presets = parsePresets(phdr, pbag, pgen, instruments)
+
+	return font, nil
+}
+
+func extractSubchunk(data []byte, want string) []byte {
+	pos := 0
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		end := pos + 8 + int(size)
+		if end > len(data) {
+			end = len(data)
+		}
+		if id == want {
+			return data[pos+8 : end]
+		}
+		pos = end
+		if size%2 == 1 {
+			pos++
+		}
+	}
+	return nil
+}
+
+func parseShdr(shdr []byte, smplData []byte) []SF2Sample {
+	const recSize = 46
+	count := len(shdr) / recSize
+	samples := make([]SF2Sample, 0, count)
+	for i := 0; i < count; i++ {
+		rec := shdr[i*recSize : (i+1)*recSize]
+		s := SF2Sample{
+			Name:            readFixedString(rec[0:20]),
+			Start:           binary.LittleEndian.Uint32(rec[20:24]),
+			End:             binary.LittleEndian.Uint32(rec[24:28]),
+			StartLoop:       binary.LittleEndian.Uint32(rec[28:32]),
+			EndLoop:         binary.LittleEndian.Uint32(rec[32:36]),
+			SampleRate:      binary.LittleEndian.Uint32(rec[36:40]),
+			OriginalPitch:   rec[40],
+			PitchCorrection: int8(rec[41]),
+		}
+		if smplData != nil && s.End*2 <= uint32(len(smplData)) && s.End > s.Start {
+			n := s.End - s.Start
+			pcm := make([]int16, n)
+			for j := uint32(0); j < n; j++ {
+				off := (s.Start + j) * 2
+				pcm[j] = int16(binary.LittleEndian.Uint16(smplData[off : off+2]))
+			}
+			s.Data = pcm
+			// ループ点をサンプル先頭基準に揃える
+			if s.StartLoop >= s.Start {
+				s.StartLoop -= s.Start
+			}
+			if s.EndLoop >= s.Start {
+				s.EndLoop -= s.Start
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+func parseInstruments(inst, ibag, igen []byte) [][]SF2Zone {
+	// instごとのゾーンをまとめて返す（ibag->igenをたどる）
+	const instRec = 22
+	const bagRec = 4
+	const genRec = 4
+
+	instCount := len(inst) / instRec
+	var bagNdx []int
+	for i := 0; i < instCount; i++ {
+		rec := inst[i*instRec : (i+1)*instRec]
+		bagNdx = append(bagNdx, int(binary.LittleEndian.Uint16(rec[20:22])))
+	}
+
+	zonesByInst := make([][]SF2Zone, instCount)
+	for i := 0; i < instCount; i++ {
+		start := bagNdx[i]
+		end := len(ibag) / bagRec
+		if i+1 < len(bagNdx) {
+			end = bagNdx[i+1]
+		}
+		for b := start; b < end; b++ {
+			if (b+1)*bagRec > len(ibag) {
+				break
+			}
+			genStart := int(binary.LittleEndian.Uint16(ibag[b*bagRec : b*bagRec+2]))
+			genEnd := len(igen) / genRec
+			if (b+1)*bagRec+2 <= len(ibag) {
+				genEnd = int(binary.LittleEndian.Uint16(ibag[(b+1)*bagRec : (b+1)*bagRec+2]))
+			}
+			zone := SF2Zone{KeyLo: 0, KeyHi: 127, VelLo: 0, VelHi: 127, SampleIndex: -1, RootKey: -1, Sustain: 1.0}
+			for g := genStart; g < genEnd; g++ {
+				if (g+1)*genRec > len(igen) {
+					break
+				}
+				oper := binary.LittleEndian.Uint16(igen[g*genRec : g*genRec+2])
+				amt := int16(binary.LittleEndian.Uint16(igen[g*genRec+2 : g*genRec+4]))
+				applyGenerator(&zone, oper, amt)
+			}
+			if zone.SampleIndex >= 0 {
+				zonesByInst[i] = append(zonesByInst[i], zone)
+			}
+		}
+	}
+
+	return zonesByInst
+}
+
+func applyGenerator(zone *SF2Zone, oper uint16, amt int16) {
+	switch oper {
+	case genKeyRange:
+		lo := int(uint8(amt & 0xFF))
+		hi := int(uint8((amt >> 8) & 0xFF))
+		zone.KeyLo, zone.KeyHi = lo, hi
+	case genVelRange:
+		lo := int(uint8(amt & 0xFF))
+		hi := int(uint8((amt >> 8) & 0xFF))
+		zone.VelLo, zone.VelHi = lo, hi
+	case genSampleID:
+		zone.SampleIndex = int(amt)
+	case genOverridingRootKey:
+		zone.RootKey = int(amt)
+	case genSampleModes:
+		zone.Loop = amt == 1 || amt == 3
+	case genAttackVolEnv:
+		zone.Attack = timecentsToSeconds(amt)
+	case genHoldVolEnv:
+		zone.Hold = timecentsToSeconds(amt)
+	case genDecayVolEnv:
+		zone.Decay = timecentsToSeconds(amt)
+	case genSustainVolEnv:
+		zone.Sustain = centibelsToSustainLevel(amt)
+	case genReleaseVolEnv:
+		zone.Release = timecentsToSeconds(amt)
+	}
+}
+
+func parsePresets(phdr, pbag, pgen []byte, instZoneTable [][]SF2Zone) []SF2Preset {
+	const phdrRec = 38
+	const bagRec = 4
+	const genRec = 4
+
+	count := len(phdr) / phdrRec
+	if count == 0 {
+		return nil
+	}
+
+	type rawPreset struct {
+		name    string
+		bank    int
+		program int
+		bagNdx  int
+	}
+	raw := make([]rawPreset, count)
+	for i := 0; i < count; i++ {
+		rec := phdr[i*phdrRec : (i+1)*phdrRec]
+		raw[i] = rawPreset{
+			name:    readFixedString(rec[0:20]),
+			program: int(binary.LittleEndian.Uint16(rec[20:22])),
+			bank:    int(binary.LittleEndian.Uint16(rec[22:24])),
+			bagNdx:  int(binary.LittleEndian.Uint16(rec[24:26])),
+		}
+	}
+
+	presets := make([]SF2Preset, 0, count)
+	for i := 0; i < count; i++ {
+		// 末尾のEOPはスキップ（phdrの最後のレコードはterminal）
+		if i == count-1 {
+			break
+		}
+		start := raw[i].bagNdx
+		end := raw[i+1].bagNdx
+
+		preset := SF2Preset{Name: raw[i].name, Bank: raw[i].bank, Program: raw[i].program}
+		for b := start; b < end; b++ {
+			if (b+1)*bagRec > len(pbag) {
+				break
+			}
+			genStart := int(binary.LittleEndian.Uint16(pbag[b*bagRec : b*bagRec+2]))
+			genEnd := len(pgen) / genRec
+			if (b+1)*bagRec+2 <= len(pbag) {
+				genEnd = int(binary.LittleEndian.Uint16(pbag[(b+1)*bagRec : (b+1)*bagRec+2]))
+			}
+			var instIdx = -1
+			zone := SF2Zone{KeyLo: 0, KeyHi: 127, VelLo: 0, VelHi: 127, SampleIndex: -1}
+			for g := genStart; g < genEnd; g++ {
+				if (g+1)*genRec > len(pgen) {
+					break
+				}
+				oper := binary.LittleEndian.Uint16(pgen[g*genRec : g*genRec+2])
+				amt := int16(binary.LittleEndian.Uint16(pgen[g*genRec+2 : g*genRec+4]))
+				if oper == genInstrument {
+					instIdx = int(amt)
+					continue
+				}
+				applyGenerator(&zone, oper, amt)
+			}
+			if instIdx >= 0 && instIdx < len(instZoneTable) {
+				for _, iz := range instZoneTable[instIdx] {
+					merged := iz
+					// プリセットゾーンのキー/ベロシティ範囲で絞り込む（インストゥルメントゾーンの範囲との共通部分）
+					if zone.KeyLo > merged.KeyLo {
+						merged.KeyLo = zone.KeyLo
+					}
+					if zone.KeyHi < merged.KeyHi {
+						merged.KeyHi = zone.KeyHi
+					}
+					if zone.VelLo > merged.VelLo {
+						merged.VelLo = zone.VelLo
+					}
+					if zone.VelHi < merged.VelHi {
+						merged.VelHi = zone.VelHi
+					}
+					preset.Zones = append(preset.Zones, merged)
+				}
+			}
+		}
+		presets = append(presets, preset)
+	}
+	return presets
+}
+
+// findZone はバンク/プログラム/ノート/ベロシティに一致するゾーンとサンプルを探す
+func (f *SF2Font) findZone(bank, program, note, velocity int) (*SF2Zone, *SF2Sample, bool) {
+	for pi := range f.Presets {
+		p := &f.Presets[pi]
+		if p.Bank != bank || p.Program != program {
+			continue
+		}
+		for zi := range p.Zones {
+			z := &p.Zones[zi]
+			if note < z.KeyLo || note > z.KeyHi {
+				continue
+			}
+			if velocity < z.VelLo || velocity > z.VelHi {
+				continue
+			}
+			if z.SampleIndex < 0 || z.SampleIndex >= len(f.Samples) {
+				continue
+			}
+			return z, &f.Samples[z.SampleIndex], true
+		}
+	}
+	return nil, nil, false
+}
+
+// sf2Envelope はAHDSR(アタック/ホールド/ディケイ/サステイン/リリース)の振幅を計算する。
+// ディレイ段(delayVolEnv)は読み込んでおらず、アタックはノート開始直後から始まる
+func sf2Envelope(z *SF2Zone, t, noteDuration, sampleRate float64) float64 {
+	attack, hold, decay, sustain, release := z.Attack, z.Hold, z.Decay, z.Sustain, z.Release
+	if t < attack {
+		if attack <= 0 {
+			return 1.0
+		}
+		return t / attack
+	}
+	t -= attack
+	if t < hold {
+		return 1.0
+	}
+	t -= hold
+	if t < decay {
+		if decay <= 0 {
+			return sustain
+		}
+		return 1.0 - (1.0-sustain)*(t/decay)
+	}
+
+	// リリース区間：ノート終了後
+	if t+attack+hold > noteDuration {
+		releaseT := (t + attack + hold) - noteDuration
+		if releaseT >= release || release <= 0 {
+			return 0
+		}
+		return sustain * (1.0 - releaseT/release)
+	}
+
+	return sustain
+}
+
+// sf2Resample はルートキーからmidiNoteへのピッチシフトを4点Hermite補間で行う
+func sf2Resample(data []int16, srcRate float64, startLoop, endLoop uint32, loop bool, ratio float64, outSamples int) []float64 {
+	out := make([]float64, outSamples)
+	pos := 0.0
+	n := len(data)
+	if n == 0 {
+		return out
+	}
+
+	sampleAt := func(idx int) float64 {
+		if idx < 0 {
+			idx = 0
+		}
+		if loop && endLoop > startLoop && uint32(idx) >= endLoop {
+			span := int(endLoop - startLoop)
+			idx = int(startLoop) + (idx-int(startLoop))%span
+		}
+		if idx >= n {
+			idx = n - 1
+		}
+		return float64(data[idx]) / 32768.0
+	}
+
+	for i := 0; i < outSamples; i++ {
+		base := int(math.Floor(pos))
+		frac := pos - float64(base)
+
+		// 4点Hermite補間
+		p0 := sampleAt(base - 1)
+		p1 := sampleAt(base)
+		p2 := sampleAt(base + 1)
+		p3 := sampleAt(base + 2)
+
+		c0 := p1
+		c1 := 0.5 * (p2 - p0)
+		c2 := p0 - 2.5*p1 + 2.0*p2 - 0.5*p3
+		c3 := 0.5*(p3-p0) + 1.5*(p1-p2)
+		out[i] = ((c3*frac+c2)*frac+c1)*frac + c0
+
+		pos += ratio
+		if loop && endLoop > startLoop && int(pos) >= n {
+			break
+		}
+	}
+	return out
+}
+
+// Voice はSF2フォントからノート1つ分の波形をレンダリングする
+func (f *SF2Font) Voice(note Note, sampleRate int) []float64 {
+	bank := 0
+	zone, sample, ok := f.findZone(bank, note.Program, note.MidiNote, note.Velocity)
+	if !ok || sample == nil || len(sample.Data) == 0 {
+		return nil
+	}
+
+	rootKey := int(sample.OriginalPitch)
+	if zone.RootKey >= 0 {
+		rootKey = zone.RootKey
+	}
+	ratio := (midiNoteToFrequency(note.MidiNote) / midiNoteToFrequency(rootKey)) * (float64(sample.SampleRate) / float64(sampleRate))
+
+	outSamples := int(note.Duration * float64(sampleRate))
+	if outSamples <= 0 {
+		return nil
+	}
+	wave := sf2Resample(sample.Data, float64(sample.SampleRate), sample.StartLoop, sample.EndLoop, zone.Loop, ratio, outSamples)
+
+	volume := float64(note.Velocity) / 127.0
+	for i := range wave {
+		t := float64(i) / float64(sampleRate)
+		env := sf2Envelope(zone, t, note.Duration, float64(sampleRate))
+		wave[i] *= volume * env
+	}
+	return wave
+}
@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestTimecentsToSeconds(t *testing.T) {
+	if got := timecentsToSeconds(-32768); got != 0 {
+		t.Errorf("sentinel -32768 should mean 0s, got %f", got)
+	}
+	if got := timecentsToSeconds(0); got < 0.999 || got > 1.001 {
+		t.Errorf("0 timecents should be 1s, got %f", got)
+	}
+	if got := timecentsToSeconds(1200); got < 1.999 || got > 2.001 {
+		t.Errorf("1200 timecents should double to 2s, got %f", got)
+	}
+}
+
+func TestCentibelsToSustainLevel(t *testing.T) {
+	cases := []struct {
+		cb   int16
+		want float64
+	}{
+		{0, 1.0},
+		{1000, 0.0},
+		{2000, 0.0}, // clamps below 0
+		{-500, 1.0}, // clamps above 1
+	}
+	for _, c := range cases {
+		if got := centibelsToSustainLevel(c.cb); got != c.want {
+			t.Errorf("centibelsToSustainLevel(%d) = %f, want %f", c.cb, got, c.want)
+		}
+	}
+}
+
+func TestFindZoneMatchesKeyAndVelocityRange(t *testing.T) {
+	font := &SF2Font{
+		Samples: []SF2Sample{{Name: "piano-lo"}, {Name: "piano-hi"}},
+		Presets: []SF2Preset{
+			{
+				Bank: 0, Program: 0,
+				Zones: []SF2Zone{
+					{KeyLo: 0, KeyHi: 59, VelLo: 0, VelHi: 127, SampleIndex: 0},
+					{KeyLo: 60, KeyHi: 127, VelLo: 0, VelHi: 127, SampleIndex: 1},
+				},
+			},
+		},
+	}
+
+	zone, sample, ok := font.findZone(0, 0, 72, 100)
+	if !ok || sample.Name != "piano-hi" {
+		t.Fatalf("expected the high zone for note 72, got zone=%+v sample=%+v ok=%v", zone, sample, ok)
+	}
+
+	zone, sample, ok = font.findZone(0, 0, 40, 100)
+	if !ok || sample.Name != "piano-lo" {
+		t.Fatalf("expected the low zone for note 40, got zone=%+v sample=%+v ok=%v", zone, sample, ok)
+	}
+
+	if _, _, ok := font.findZone(0, 5, 60, 100); ok {
+		t.Errorf("expected no match for an unknown program")
+	}
+}
+
+func TestFindZoneOutOfRangeSampleIndexIsSkipped(t *testing.T) {
+	font := &SF2Font{
+		Samples: []SF2Sample{{Name: "only"}},
+		Presets: []SF2Preset{
+			{
+				Bank: 0, Program: 0,
+				Zones: []SF2Zone{
+					{KeyLo: 0, KeyHi: 127, VelLo: 0, VelHi: 127, SampleIndex: 5}, // out of range
+				},
+			},
+		},
+	}
+	if _, _, ok := font.findZone(0, 0, 60, 100); ok {
+		t.Errorf("expected no match when the zone's SampleIndex is out of range")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func tempoMetaEvent(deltaTime, bpm int) MidiEvent {
+	micros := 60000000 / bpm
+	return MidiEvent{
+		Type:      Meta,
+		DeltaTime: deltaTime,
+		MetaType:  MetaSetTempo,
+		Data:      []byte{byte(micros >> 16), byte(micros >> 8), byte(micros)},
+	}
+}
+
+func TestBuildTempoMapDefaultsWhenNoSetTempo(t *testing.T) {
+	midiFile := &MidiFile{Format: 0, Tracks: []MidiTrack{{Events: nil}}}
+	changes := buildTempoMap(midiFile, 120.0)
+	if len(changes) != 1 || changes[0].Tick != 0 {
+		t.Fatalf("expected a single tick-0 default entry, got %+v", changes)
+	}
+	if got := changes[0].MicrosPerQuarter; got != 500000 {
+		t.Errorf("120bpm should be 500000 micros/quarter, got %d", got)
+	}
+}
+
+func TestBuildTempoMapSortsAndKeepsExplicitChanges(t *testing.T) {
+	midiFile := &MidiFile{
+		Format: 2, // all tracks contribute; the later change comes first so the result must be sorted
+		Tracks: []MidiTrack{
+			{Events: []MidiEvent{tempoMetaEvent(960, 60)}},
+			{Events: []MidiEvent{tempoMetaEvent(0, 120)}},
+		},
+	}
+	changes := buildTempoMap(midiFile, 90.0)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 tempo changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].Tick != 0 || changes[0].MicrosPerQuarter != 500000 {
+		t.Errorf("first change should be tick 0 @ 120bpm, got %+v", changes[0])
+	}
+	if changes[1].Tick != 960 || changes[1].MicrosPerQuarter != 1000000 {
+		t.Errorf("second change should be tick 960 @ 60bpm, got %+v", changes[1])
+	}
+}
+
+func TestMakeTicksToSecondsConstantTempo(t *testing.T) {
+	tempoMap := []TempoChange{{Tick: 0, MicrosPerQuarter: 500000}} // 120bpm
+	ticksToSeconds := makeTicksToSeconds(480, tempoMap)
+	if got := ticksToSeconds(480); got < 0.4999 || got > 0.5001 {
+		t.Errorf("480 ticks @ 120bpm should be ~0.5s, got %f", got)
+	}
+	if got := ticksToSeconds(0); got != 0 {
+		t.Errorf("tick 0 should be 0s, got %f", got)
+	}
+}
+
+func TestMakeTicksToSecondsAcrossTempoChange(t *testing.T) {
+	// 120bpm for the first 480 ticks, then 60bpm
+	tempoMap := []TempoChange{
+		{Tick: 0, MicrosPerQuarter: 500000},
+		{Tick: 480, MicrosPerQuarter: 1000000},
+	}
+	ticksToSeconds := makeTicksToSeconds(480, tempoMap)
+	got := ticksToSeconds(960)
+	want := 0.5 + 1.0 // 480 ticks @120bpm + 480 ticks @60bpm
+	if got < want-0.0001 || got > want+0.0001 {
+		t.Errorf("expected %f seconds across the tempo change, got %f", want, got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// このファイルのWavWriterは本来wavサブパッケージに切り出す想定だったが、
+// このリポジトリにはgo.modがなく真のサブパッケージ（独立したimportパス）を
+// 作れないため、既存のpackage main構成に合わせてここに置いている。
+// 旧来のwriteWaveFile（main.go）はデモ生成パス専用として残してあり、
+// convertMidiToWavはこちらのWavWriterのみを使う。WaveHeader（main.go）はWavWriterのヘッダー書き込みにも使う。
+
+// SampleFormat はPCM/floatのサンプルフォーマット
+type SampleFormat int
+
+const (
+	FormatUint8 SampleFormat = iota
+	FormatInt16
+	FormatInt24
+	FormatInt32
+	FormatFloat32
+)
+
+// WavConfig はストリーミングWAVライターの設定
+type WavConfig struct {
+	Channels   int
+	SampleRate int
+	Format     SampleFormat
+}
+
+func (f SampleFormat) bitsPerSample() int {
+	switch f {
+	case FormatUint8:
+		return 8
+	case FormatInt16:
+		return 16
+	case FormatInt24:
+		return 24
+	case FormatInt32, FormatFloat32:
+		return 32
+	default:
+		return 16
+	}
+}
+
+func (f SampleFormat) audioFormatTag() uint16 {
+	if f == FormatFloat32 {
+		return 3 // IEEE float
+	}
+	return 1 // PCM
+}
+
+// WavWriter はサンプルをブロック単位で書き出し、Close時にヘッダーのサイズを確定させる
+// streaming WAVライター。waveパッケージでよくあるNewWriter/Write/Closeのパターンに倣う。
+type WavWriter struct {
+	w           io.WriteSeeker
+	cfg         WavConfig
+	bytesPerSmp int
+	dataBytes   uint32
+}
+
+// NewWavWriter はプレースホルダーのヘッダーを書き込み、後でCloseがサイズを patch する
+func NewWavWriter(w io.WriteSeeker, cfg WavConfig) (*WavWriter, error) {
+	if cfg.Channels != 1 && cfg.Channels != 2 {
+		return nil, fmt.Errorf("unsupported channel count: %d", cfg.Channels)
+	}
+
+	bitsPerSample := cfg.Format.bitsPerSample()
+	blockAlign := cfg.Channels * bitsPerSample / 8
+
+	header := WaveHeader{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     36,
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   cfg.Format.audioFormatTag(),
+		NumChannels:   uint16(cfg.Channels),
+		SampleRate:    uint32(cfg.SampleRate),
+		ByteRate:      uint32(cfg.SampleRate * blockAlign),
+		BlockAlign:    uint16(blockAlign),
+		BitsPerSample: uint16(bitsPerSample),
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: 0,
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return nil, err
+	}
+
+	return &WavWriter{w: w, cfg: cfg, bytesPerSmp: bitsPerSample / 8}, nil
+}
+
+// Write は正規化済み([-1,1])のサンプル列を現在のフォーマットにエンコードして書き込む。
+// ステレオの場合、呼び出し側がL,R,L,R,...の順でインターリーブ済みの値を渡す。
+func (ww *WavWriter) Write(samples []float64) error {
+	for _, s := range samples {
+		clamped := math.Max(-1.0, math.Min(1.0, s))
+
+		var err error
+		switch ww.cfg.Format {
+		case FormatUint8:
+			v := uint8((clamped*0.5 + 0.5) * 255.0)
+			err = binary.Write(ww.w, binary.LittleEndian, v)
+		case FormatInt16:
+			v := int16(clamped * 32767.0)
+			err = binary.Write(ww.w, binary.LittleEndian, v)
+		case FormatInt24:
+			iv := int32(clamped * 8388607.0)
+			buf := [3]byte{byte(iv), byte(iv >> 8), byte(iv >> 16)}
+			_, err = ww.w.Write(buf[:])
+		case FormatInt32:
+			v := int32(clamped * 2147483647.0)
+			err = binary.Write(ww.w, binary.LittleEndian, v)
+		case FormatFloat32:
+			err = binary.Write(ww.w, binary.LittleEndian, float32(clamped))
+		}
+		if err != nil {
+			return err
+		}
+		ww.dataBytes += uint32(ww.bytesPerSmp)
+	}
+	return nil
+}
+
+// Close はRIFFチャンクサイズとdataチャンクサイズをシークして書き戻す
+func (ww *WavWriter) Close() error {
+	if _, err := ww.w.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(ww.w, binary.LittleEndian, uint32(36+ww.dataBytes)); err != nil {
+		return err
+	}
+	if _, err := ww.w.Seek(40, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(ww.w, binary.LittleEndian, ww.dataBytes); err != nil {
+		return err
+	}
+	_, err := ww.w.Seek(0, io.SeekEnd)
+	return err
+}